@@ -1,108 +1,162 @@
-package oauth2cli
-
-import (
-	"context"
-	"errors"
-	"fmt"
-	"math/rand"
-	"net"
-	"net/http"
-	"strconv"
-
-	"github.com/pkg/browser"
-	"golang.org/x/oauth2"
-)
-
-// DefaultPort is the default port for the local callback server.
-const DefaultPort = 4321
-
-// Token completes the OAuth2 flow, and returns a token from a code exchange.
-//
-// It stands up a temporary HTTP server on the host's localPort port in order to handle
-// the OAuth2 callback. If an error occurs during the callback handling - such as
-// invalid state, or a missing code, an error will be returned.
-//
-// The server will be shutdown after handling the first request, regardless of success or failure.
-//
-// If localPort is 0, it will use DefaultPort.
-// The user's browser will be redirected to the URL provided. If it isn't, no redirect will occur.
-func Token(ctx context.Context, cfg *oauth2.Config, localPort int, redirect string) (*oauth2.Token, error) {
-	state := strconv.Itoa(rand.Int())
-	url := cfg.AuthCodeURL(state)
-
-	shutdown := make(chan struct{})
-	errC := make(chan error, 2)
-
-	var code string
-
-	// OAuth2 callback handler on the default mux.
-	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
-		defer func() {
-			close(errC)
-			shutdown <- struct{}{}
-		}()
-
-		q := r.URL.Query()
-
-		// Check state matches.
-		if s := q.Get("state"); s != state {
-			errC <- fmt.Errorf("invalid state received: %s, expected %s", s, state)
-			return
-		}
-
-		code = q.Get("code")
-		if code == "" {
-			errC <- errors.New("no code received")
-			return
-		}
-
-		// Code is valid, respond with a redirect if provided.
-		if redirect != "" {
-			http.Redirect(w, r, redirect, http.StatusSeeOther)
-		}
-	})
-
-	if localPort == 0 {
-		localPort = DefaultPort
-	}
-
-	// Setup server on the local machine using the provided port number.
-	// The server will use the default handler mux.
-	server := &http.Server{
-		Addr: net.JoinHostPort("127.0.0.1", strconv.Itoa(localPort)),
-	}
-
-	// Start a go routine to shutdown the local callback server when the flow is complete.
-	go func() {
-		// Block until the flow is complete.
-		<-shutdown
-
-		if err := server.Shutdown(ctx); err != nil {
-			errC <- fmt.Errorf("failed to shutdown server: %w", err)
-		}
-	}()
-
-	// Open the user's browser to auth code URL as defined by their OAuth config.
-	if err := browser.OpenURL(url); err != nil {
-		return nil, fmt.Errorf("could not open browser for auth: %w", err)
-	}
-
-	// Start the callback server.
-	// This will block until it's shutdown.
-	if err := server.ListenAndServe(); err != http.ErrServerClosed {
-		return nil, err
-	}
-
-	// Check for handler errors.
-	if len(errC) > 0 {
-		return nil, <-errC
-	}
-
-	// Exchange the code for an OAuth2 token.
-	token, err := cfg.Exchange(ctx, code)
-	if err != nil {
-		return nil, fmt.Errorf("could not exchange for token: %w", err)
-	}
-
-	return token, nil
-}
+package oauth2cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+
+	"github.com/pkg/browser"
+	"golang.org/x/oauth2"
+)
+
+// DefaultPort is a port commonly used for the local callback server. It is not used
+// automatically; pass it explicitly if your provider requires a fixed redirect port.
+const DefaultPort = 4321
+
+// openBrowser opens authURL in the user's default browser. It's a variable so tests can
+// substitute a stub rather than requiring a real browser.
+var openBrowser = browser.OpenURL
+
+// Token completes the OAuth2 flow, and returns a token from a code exchange.
+//
+// It stands up a temporary HTTP server on the host's localPort port in order to handle
+// the OAuth2 callback. If an error occurs during the callback handling - such as
+// invalid state, or a missing code, an error will be returned.
+//
+// The server will be shutdown after handling the first request, regardless of success or failure.
+//
+// If localPort is 0, an OS-assigned free port is used and cfg.RedirectURL is set
+// automatically - the right choice for desktop OAuth apps that register a loopback
+// redirect with no fixed port.
+//
+// If redirect is non-empty and no success page is configured (see WithSuccessHTML and
+// WithSuccessTemplate), the user's browser is redirected to it once a valid code has
+// been received. If redirect is empty, no redirect will occur.
+//
+// Public clients that can't hold a client secret should pass WithPKCE() so the code
+// exchange is bound to this flow via RFC 7636.
+func Token(ctx context.Context, cfg *oauth2.Config, localPort int, redirect string, opts ...Option) (*oauth2.Token, error) {
+	o := applyOptions(opts)
+
+	state, err := randomToken(16)
+	if err != nil {
+		return nil, err
+	}
+
+	verifier, authParams, err := pkceAuthParams(o)
+	if err != nil {
+		return nil, err
+	}
+
+	code, err := runLoopback(ctx, cfg, localPort, redirect, state, o, authParams)
+	if err != nil {
+		return nil, err
+	}
+
+	exchangeParams := pkceExchangeParams(o, verifier)
+
+	// Exchange the code for an OAuth2 token.
+	token, err := cfg.Exchange(ctx, code, exchangeParams...)
+	if err != nil {
+		return nil, fmt.Errorf("could not exchange for token: %w", err)
+	}
+
+	return token, nil
+}
+
+// runLoopback stands up a temporary HTTP server on localPort (or an OS-assigned free port
+// if 0), setting cfg.RedirectURL to match before opening the user's browser to the
+// resulting auth URL. It blocks until the OAuth2 callback is received, returning the code
+// once state has been checked against the expected value.
+func runLoopback(ctx context.Context, cfg *oauth2.Config, localPort int, redirect, state string, o *options, authParams []oauth2.AuthCodeOption) (string, error) {
+	l, err := net.Listen("tcp", net.JoinHostPort("127.0.0.1", strconv.Itoa(localPort)))
+	if err != nil {
+		return "", fmt.Errorf("could not start callback listener: %w", err)
+	}
+
+	// Derive RedirectURL when the port is OS-assigned, or when the caller hasn't pinned one
+	// of their own - providers enforce exact redirect_uri matching, so a caller who
+	// registered a fixed port and RedirectURL must have it left alone.
+	if localPort == 0 || cfg.RedirectURL == "" {
+		cfg.RedirectURL = fmt.Sprintf("http://%s/", l.Addr().String())
+	}
+	authURL := cfg.AuthCodeURL(state, authParams...)
+
+	shutdown := make(chan struct{})
+	errC := make(chan error, 2)
+
+	var code string
+
+	// OAuth2 callback handler on a mux scoped to this call, so Token can be called more
+	// than once in the same process without panicking on duplicate route registration.
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		defer func() {
+			close(errC)
+			shutdown <- struct{}{}
+		}()
+
+		q := r.URL.Query()
+
+		var handlerErr error
+		switch {
+		case q.Get("state") != state:
+			handlerErr = fmt.Errorf("invalid state received: %s, expected %s", q.Get("state"), state)
+		case q.Get("code") == "":
+			handlerErr = errors.New("no code received")
+		default:
+			code = q.Get("code")
+		}
+
+		if handlerErr != nil {
+			errC <- handlerErr
+		}
+
+		if o.hasSuccessPage() {
+			data := SuccessPageData{AppName: o.appName}
+			if handlerErr != nil {
+				data.Error = handlerErr.Error()
+			}
+			renderSuccessPage(w, o, data)
+			return
+		}
+
+		// Code is valid, respond with a redirect if provided.
+		if handlerErr == nil && redirect != "" {
+			http.Redirect(w, r, redirect, http.StatusSeeOther)
+		}
+	})
+
+	server := &http.Server{Handler: mux}
+
+	// Start a go routine to shutdown the local callback server when the flow is complete.
+	go func() {
+		// Block until the flow is complete.
+		<-shutdown
+
+		if err := server.Shutdown(ctx); err != nil {
+			errC <- fmt.Errorf("failed to shutdown server: %w", err)
+		}
+	}()
+
+	// Open the user's browser to auth code URL as defined by their OAuth config.
+	if err := openBrowser(authURL); err != nil {
+		return "", fmt.Errorf("could not open browser for auth: %w", err)
+	}
+
+	// Start the callback server.
+	// This will block until it's shutdown.
+	if err := server.Serve(l); err != http.ErrServerClosed {
+		return "", err
+	}
+
+	// Check for handler errors.
+	if len(errC) > 0 {
+		return "", <-errC
+	}
+
+	return code, nil
+}
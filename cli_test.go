@@ -0,0 +1,229 @@
+package oauth2cli
+
+import (
+	"context"
+	"fmt"
+	"html/template"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+
+	"golang.org/x/oauth2"
+)
+
+// loopbackResult carries the return values of a runLoopback call started on a background
+// goroutine, for collection once the simulated callback has been delivered.
+type loopbackResult struct {
+	code string
+	err  error
+}
+
+// stubOpenBrowser replaces openBrowser with fn for the duration of the test, so runLoopback
+// doesn't try to launch a real browser.
+func stubOpenBrowser(t *testing.T, fn func(string) error) {
+	t.Helper()
+
+	orig := openBrowser
+	openBrowser = fn
+	t.Cleanup(func() { openBrowser = orig })
+}
+
+// freePort reserves and immediately releases a free TCP port on 127.0.0.1, for tests that
+// need to pass runLoopback a fixed, known-available port.
+func freePort(t *testing.T) int {
+	t.Helper()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("could not reserve a free port: %v", err)
+	}
+	defer l.Close()
+
+	return l.Addr().(*net.TCPAddr).Port
+}
+
+// startLoopback runs runLoopback on a background goroutine and returns the auth URL it
+// generated (captured via a stubbed openBrowser) along with a channel that receives its
+// result once a callback request has been served.
+func startLoopback(t *testing.T, cfg *oauth2.Config, localPort int, state string, o *options) (authURL string, result <-chan loopbackResult) {
+	t.Helper()
+
+	urlCh := make(chan string, 1)
+	stubOpenBrowser(t, func(u string) error {
+		urlCh <- u
+		return nil
+	})
+
+	resultCh := make(chan loopbackResult, 1)
+	go func() {
+		code, err := runLoopback(context.Background(), cfg, localPort, "", state, o, nil)
+		resultCh <- loopbackResult{code: code, err: err}
+	}()
+
+	return <-urlCh, resultCh
+}
+
+func TestRunLoopbackAutoAssignsPort(t *testing.T) {
+	cfg := &oauth2.Config{ClientID: "client"}
+
+	_, resultCh := startLoopback(t, cfg, 0, "expected-state", &options{})
+
+	if !strings.HasPrefix(cfg.RedirectURL, "http://127.0.0.1:") {
+		t.Fatalf("cfg.RedirectURL = %q, want an auto-assigned http://127.0.0.1:<port>/ value", cfg.RedirectURL)
+	}
+
+	resp, err := http.Get(cfg.RedirectURL + "?state=expected-state&code=auth-code")
+	if err != nil {
+		t.Fatalf("callback request failed: %v", err)
+	}
+	resp.Body.Close()
+
+	res := <-resultCh
+	if res.err != nil {
+		t.Fatalf("runLoopback() returned error: %v", res.err)
+	}
+	if res.code != "auth-code" {
+		t.Errorf("code = %q, want auth-code", res.code)
+	}
+}
+
+func TestRunLoopbackLeavesFixedRedirectURLAlone(t *testing.T) {
+	port := freePort(t)
+	cfg := &oauth2.Config{ClientID: "client", RedirectURL: "https://example.com/callback"}
+
+	startLoopback(t, cfg, port, "expected-state", &options{})
+
+	if cfg.RedirectURL != "https://example.com/callback" {
+		t.Errorf("cfg.RedirectURL = %q, want it left unchanged", cfg.RedirectURL)
+	}
+
+	resp, err := http.Get(fmt.Sprintf("http://127.0.0.1:%d/?state=expected-state&code=auth-code", port))
+	if err != nil {
+		t.Fatalf("callback request failed: %v", err)
+	}
+	resp.Body.Close()
+}
+
+func TestRunLoopbackRejectsStateMismatch(t *testing.T) {
+	cfg := &oauth2.Config{ClientID: "client"}
+
+	_, resultCh := startLoopback(t, cfg, 0, "expected-state", &options{})
+
+	resp, err := http.Get(cfg.RedirectURL + "?state=wrong-state&code=auth-code")
+	if err != nil {
+		t.Fatalf("callback request failed: %v", err)
+	}
+	resp.Body.Close()
+
+	res := <-resultCh
+	if res.err == nil {
+		t.Fatal("runLoopback() expected an error for mismatched state, got nil")
+	}
+}
+
+func TestRunLoopbackRejectsMissingCode(t *testing.T) {
+	cfg := &oauth2.Config{ClientID: "client"}
+
+	_, resultCh := startLoopback(t, cfg, 0, "expected-state", &options{})
+
+	resp, err := http.Get(cfg.RedirectURL + "?state=expected-state")
+	if err != nil {
+		t.Fatalf("callback request failed: %v", err)
+	}
+	resp.Body.Close()
+
+	res := <-resultCh
+	if res.err == nil {
+		t.Fatal("runLoopback() expected an error for a missing code, got nil")
+	}
+}
+
+func TestRunLoopbackRendersSuccessHTML(t *testing.T) {
+	cfg := &oauth2.Config{ClientID: "client"}
+	o := applyOptions([]Option{
+		WithAppName("Example App"),
+		WithSuccessHTML("App: {{.AppName}}, Error: {{.Error}}"),
+	})
+
+	_, resultCh := startLoopback(t, cfg, 0, "expected-state", o)
+
+	resp, err := http.Get(cfg.RedirectURL + "?state=expected-state&code=auth-code")
+	if err != nil {
+		t.Fatalf("callback request failed: %v", err)
+	}
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		t.Fatalf("could not read success page body: %v", err)
+	}
+
+	if want := "App: Example App, Error: "; string(body) != want {
+		t.Errorf("success page body = %q, want %q", body, want)
+	}
+
+	if res := <-resultCh; res.err != nil {
+		t.Fatalf("runLoopback() returned error: %v", res.err)
+	}
+}
+
+func TestRunLoopbackRendersSuccessTemplateOnError(t *testing.T) {
+	cfg := &oauth2.Config{ClientID: "client"}
+	tmpl := template.Must(template.New("success").Parse("App: {{.AppName}}, Error: {{.Error}}"))
+	o := applyOptions([]Option{
+		WithAppName("Example App"),
+		WithSuccessTemplate(tmpl),
+	})
+
+	_, resultCh := startLoopback(t, cfg, 0, "expected-state", o)
+
+	resp, err := http.Get(cfg.RedirectURL + "?state=wrong-state&code=auth-code")
+	if err != nil {
+		t.Fatalf("callback request failed: %v", err)
+	}
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		t.Fatalf("could not read success page body: %v", err)
+	}
+
+	if want := "App: Example App, Error: invalid state received: wrong-state, expected expected-state"; string(body) != want {
+		t.Errorf("success page body = %q, want %q", body, want)
+	}
+
+	if res := <-resultCh; res.err == nil {
+		t.Fatal("runLoopback() expected an error for mismatched state, got nil")
+	}
+}
+
+func TestRunLoopbackSuccessPageExecuteErrorFallsBackCleanly(t *testing.T) {
+	cfg := &oauth2.Config{ClientID: "client"}
+	// Literal text before a field the data struct doesn't have, so Execute fails only
+	// after it has already written output - if renderSuccessPage didn't buffer first,
+	// the response would contain this prefix followed by the fallback body.
+	o := applyOptions([]Option{
+		WithSuccessHTML("partial output before the bad field: {{.Nonexistent}}"),
+	})
+
+	_, resultCh := startLoopback(t, cfg, 0, "expected-state", o)
+
+	resp, err := http.Get(cfg.RedirectURL + "?state=expected-state&code=auth-code")
+	if err != nil {
+		t.Fatalf("callback request failed: %v", err)
+	}
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		t.Fatalf("could not read success page body: %v", err)
+	}
+
+	// http.Error appends a trailing newline to the message it's given.
+	if got := strings.TrimSuffix(string(body), "\n"); got != fallbackSuccessBody {
+		t.Errorf("success page body = %q, want exactly fallbackSuccessBody %q", body, fallbackSuccessBody)
+	}
+
+	if res := <-resultCh; res.err != nil {
+		t.Fatalf("runLoopback() returned error: %v", res.err)
+	}
+}
@@ -0,0 +1,237 @@
+package oauth2cli
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+// WithDeviceOutput sets the writer DeviceToken prints the user code and verification URI
+// to. Defaults to os.Stdout.
+func WithDeviceOutput(w io.Writer) Option {
+	return func(o *options) {
+		o.deviceOut = w
+	}
+}
+
+// WithQRCode sets a function DeviceToken calls with the complete verification URI, for
+// rendering a scannable QR code alongside the printed instructions. It is not called if
+// the provider doesn't return a verification_uri_complete.
+func WithQRCode(fn func(w io.Writer, verificationURIComplete string)) Option {
+	return func(o *options) {
+		o.qrCode = fn
+	}
+}
+
+// deviceAuthResponse is the device authorization endpoint's response, per RFC 8628
+// section 3.2.
+type deviceAuthResponse struct {
+	DeviceCode              string `json:"device_code"`
+	UserCode                string `json:"user_code"`
+	VerificationURI         string `json:"verification_uri"`
+	VerificationURIComplete string `json:"verification_uri_complete"`
+	ExpiresIn               int    `json:"expires_in"`
+	Interval                int    `json:"interval"`
+}
+
+const defaultDevicePollInterval = 5 * time.Second
+
+// DeviceToken performs the OAuth2 device authorization grant (RFC 8628), for machines
+// where no browser is reachable at all - TVs, containers, SSH sessions.
+//
+// It posts cfg.ClientID, cfg.ClientSecret (for confidential clients) and cfg.Scopes to
+// deviceAuthURL, prints the user code and verification URI to the configured device
+// output (see WithDeviceOutput), then polls tokenURL until the user completes the flow
+// elsewhere, honouring the authorization_pending, slow_down, access_denied and
+// expired_token responses defined by the spec.
+//
+// The returned token is a regular *oauth2.Token, usable with cfg.TokenSource like any
+// other flow in this package.
+func DeviceToken(ctx context.Context, cfg *oauth2.Config, deviceAuthURL, tokenURL string, opts ...Option) (*oauth2.Token, error) {
+	o := applyOptions(opts)
+
+	out := o.deviceOut
+	if out == nil {
+		out = os.Stdout
+	}
+
+	auth, err := requestDeviceAuth(ctx, deviceAuthURL, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	fmt.Fprintf(out, "To sign in, use a browser to open %s and enter the code: %s\n", auth.VerificationURI, auth.UserCode)
+	if o.qrCode != nil && auth.VerificationURIComplete != "" {
+		o.qrCode(out, auth.VerificationURIComplete)
+	}
+
+	interval := time.Duration(auth.Interval) * time.Second
+	if interval <= 0 {
+		interval = defaultDevicePollInterval
+	}
+
+	var deadline <-chan time.Time
+	if auth.ExpiresIn > 0 {
+		timer := time.NewTimer(time.Duration(auth.ExpiresIn) * time.Second)
+		defer timer.Stop()
+		deadline = timer.C
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-deadline:
+			return nil, errors.New("device code expired before authorization completed")
+		case <-time.After(interval):
+		}
+
+		token, err := pollDeviceToken(ctx, tokenURL, cfg, auth.DeviceCode)
+		switch {
+		case err == nil:
+			return token, nil
+		case errors.Is(err, errAuthorizationPending):
+			continue
+		case errors.Is(err, errSlowDown):
+			interval += 5 * time.Second
+			continue
+		default:
+			return nil, err
+		}
+	}
+}
+
+var (
+	errAuthorizationPending = errors.New("authorization_pending")
+	errSlowDown             = errors.New("slow_down")
+)
+
+func requestDeviceAuth(ctx context.Context, deviceAuthURL string, cfg *oauth2.Config) (*deviceAuthResponse, error) {
+	form := url.Values{"client_id": {cfg.ClientID}}
+	if len(cfg.Scopes) > 0 {
+		form.Set("scope", strings.Join(cfg.Scopes, " "))
+	}
+	if cfg.ClientSecret != "" {
+		form.Set("client_secret", cfg.ClientSecret)
+	}
+
+	body, err := postForm(ctx, deviceAuthURL, form)
+	if err != nil {
+		return nil, fmt.Errorf("could not request device authorization: %w", err)
+	}
+
+	var auth deviceAuthResponse
+	if err := json.Unmarshal(body, &auth); err != nil {
+		return nil, fmt.Errorf("could not parse device authorization response: %w", err)
+	}
+
+	return &auth, nil
+}
+
+// deviceTokenErrorResponse is the token endpoint's error response as defined by RFC 6749
+// section 5.2, extended with the device-flow-specific error codes from RFC 8628 section 3.5.
+type deviceTokenErrorResponse struct {
+	Error string `json:"error"`
+}
+
+func pollDeviceToken(ctx context.Context, tokenURL string, cfg *oauth2.Config, deviceCode string) (*oauth2.Token, error) {
+	form := url.Values{
+		"grant_type":  {"urn:ietf:params:oauth:grant-type:device_code"},
+		"device_code": {deviceCode},
+		"client_id":   {cfg.ClientID},
+	}
+	if cfg.ClientSecret != "" {
+		form.Set("client_secret", cfg.ClientSecret)
+	}
+
+	body, err := postForm(ctx, tokenURL, form)
+	if err != nil {
+		var statusErr *httpStatusError
+		if errors.As(err, &statusErr) {
+			var errResp deviceTokenErrorResponse
+			_ = json.Unmarshal(statusErr.body, &errResp)
+
+			switch errResp.Error {
+			case "authorization_pending":
+				return nil, errAuthorizationPending
+			case "slow_down":
+				return nil, errSlowDown
+			case "access_denied":
+				return nil, errors.New("access denied by user")
+			case "expired_token":
+				return nil, errors.New("device code expired")
+			}
+		}
+
+		return nil, fmt.Errorf("could not poll for token: %w", err)
+	}
+
+	var tok struct {
+		AccessToken  string `json:"access_token"`
+		TokenType    string `json:"token_type"`
+		RefreshToken string `json:"refresh_token"`
+		ExpiresIn    int    `json:"expires_in"`
+	}
+	if err := json.Unmarshal(body, &tok); err != nil {
+		return nil, fmt.Errorf("could not parse token response: %w", err)
+	}
+
+	token := &oauth2.Token{
+		AccessToken:  tok.AccessToken,
+		TokenType:    tok.TokenType,
+		RefreshToken: tok.RefreshToken,
+	}
+	if tok.ExpiresIn > 0 {
+		token.Expiry = time.Now().Add(time.Duration(tok.ExpiresIn) * time.Second)
+	}
+
+	return token, nil
+}
+
+// httpStatusError is returned by postForm when the server responds with a non-2xx status,
+// carrying the raw body so callers can inspect a provider-specific error payload.
+type httpStatusError struct {
+	status string
+	body   []byte
+}
+
+func (e *httpStatusError) Error() string {
+	return fmt.Sprintf("request failed: %s: %s", e.status, e.body)
+}
+
+// postForm POSTs an application/x-www-form-urlencoded body to urlStr and returns the
+// response body, or an *httpStatusError if the response status wasn't 2xx.
+func postForm(ctx context.Context, urlStr string, form url.Values) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, urlStr, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, fmt.Errorf("could not build request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("could not read response: %w", err)
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, &httpStatusError{status: resp.Status, body: body}
+	}
+
+	return body, nil
+}
@@ -0,0 +1,205 @@
+package oauth2cli
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+func newDeviceAuthServer(t *testing.T, interval, expiresIn int) *httptest.Server {
+	t.Helper()
+
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			t.Fatalf("could not parse device authorization form: %v", err)
+		}
+		if got := r.Form.Get("client_id"); got != "test-client" {
+			t.Errorf("client_id = %q, want test-client", got)
+		}
+
+		resp := deviceAuthResponse{
+			DeviceCode:      "device-code",
+			UserCode:        "USER-CODE",
+			VerificationURI: "https://example.com/device",
+			ExpiresIn:       expiresIn,
+			Interval:        interval,
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(resp)
+	}))
+}
+
+// newTokenErrorServer serves errs in order on successive polls, then a valid token
+// response for every poll thereafter.
+func newTokenErrorServer(errs ...string) *httptest.Server {
+	var mu sync.Mutex
+	i := 0
+
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		if i < len(errs) {
+			w.WriteHeader(http.StatusBadRequest)
+			_ = json.NewEncoder(w).Encode(map[string]string{"error": errs[i]})
+			i++
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"access_token": "access-token",
+			"token_type":   "Bearer",
+		})
+	}))
+}
+
+func TestDeviceTokenPollsThroughAuthorizationPending(t *testing.T) {
+	deviceSrv := newDeviceAuthServer(t, 1, 60)
+	defer deviceSrv.Close()
+
+	tokenSrv := newTokenErrorServer("authorization_pending", "authorization_pending")
+	defer tokenSrv.Close()
+
+	cfg := &oauth2.Config{ClientID: "test-client"}
+
+	tok, err := DeviceToken(context.Background(), cfg, deviceSrv.URL, tokenSrv.URL, WithDeviceOutput(io.Discard))
+	if err != nil {
+		t.Fatalf("DeviceToken() returned error: %v", err)
+	}
+	if tok.AccessToken != "access-token" {
+		t.Errorf("AccessToken = %q, want access-token", tok.AccessToken)
+	}
+}
+
+func TestDeviceTokenBacksOffOnSlowDown(t *testing.T) {
+	deviceSrv := newDeviceAuthServer(t, 1, 60)
+	defer deviceSrv.Close()
+
+	var mu sync.Mutex
+	var pollTimes []time.Time
+	slowedDown := false
+
+	tokenSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		pollTimes = append(pollTimes, time.Now())
+		mu.Unlock()
+
+		if !slowedDown {
+			slowedDown = true
+			w.WriteHeader(http.StatusBadRequest)
+			_ = json.NewEncoder(w).Encode(map[string]string{"error": "slow_down"})
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"access_token": "access-token",
+			"token_type":   "Bearer",
+		})
+	}))
+	defer tokenSrv.Close()
+
+	cfg := &oauth2.Config{ClientID: "test-client"}
+	start := time.Now()
+
+	tok, err := DeviceToken(context.Background(), cfg, deviceSrv.URL, tokenSrv.URL, WithDeviceOutput(io.Discard))
+	if err != nil {
+		t.Fatalf("DeviceToken() returned error: %v", err)
+	}
+	if tok.AccessToken != "access-token" {
+		t.Fatalf("AccessToken = %q, want access-token", tok.AccessToken)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(pollTimes) != 2 {
+		t.Fatalf("got %d poll requests, want 2", len(pollTimes))
+	}
+
+	firstGap := pollTimes[0].Sub(start)
+	secondGap := pollTimes[1].Sub(pollTimes[0])
+
+	// The base interval is 1s; slow_down adds another 5s before the next poll. The check
+	// allows 1s of slack for request latency, so it requires at least 4s more.
+	if secondGap < firstGap+4*time.Second {
+		t.Errorf("gap after slow_down = %v, want at least %v more than the initial gap of %v", secondGap, 4*time.Second, firstGap)
+	}
+}
+
+func TestDeviceTokenFailsFastOnAccessDenied(t *testing.T) {
+	deviceSrv := newDeviceAuthServer(t, 1, 60)
+	defer deviceSrv.Close()
+
+	tokenSrv := newTokenErrorServer("access_denied")
+	defer tokenSrv.Close()
+
+	cfg := &oauth2.Config{ClientID: "test-client"}
+
+	if _, err := DeviceToken(context.Background(), cfg, deviceSrv.URL, tokenSrv.URL, WithDeviceOutput(io.Discard)); err == nil {
+		t.Fatal("DeviceToken() expected an error for access_denied, got nil")
+	}
+}
+
+func TestDeviceTokenFailsFastOnExpiredToken(t *testing.T) {
+	deviceSrv := newDeviceAuthServer(t, 1, 60)
+	defer deviceSrv.Close()
+
+	tokenSrv := newTokenErrorServer("expired_token")
+	defer tokenSrv.Close()
+
+	cfg := &oauth2.Config{ClientID: "test-client"}
+
+	if _, err := DeviceToken(context.Background(), cfg, deviceSrv.URL, tokenSrv.URL, WithDeviceOutput(io.Discard)); err == nil {
+		t.Fatal("DeviceToken() expected an error for expired_token, got nil")
+	}
+}
+
+func TestDeviceTokenTimesOutAtDeadline(t *testing.T) {
+	deviceSrv := newDeviceAuthServer(t, 1, 1)
+	defer deviceSrv.Close()
+
+	tokenSrv := newTokenErrorServer(
+		"authorization_pending", "authorization_pending", "authorization_pending",
+		"authorization_pending", "authorization_pending", "authorization_pending",
+	)
+	defer tokenSrv.Close()
+
+	cfg := &oauth2.Config{ClientID: "test-client"}
+
+	if _, err := DeviceToken(context.Background(), cfg, deviceSrv.URL, tokenSrv.URL, WithDeviceOutput(io.Discard)); err == nil {
+		t.Fatal("DeviceToken() expected an error once the device code expired, got nil")
+	}
+}
+
+func TestRequestDeviceAuthIncludesClientSecret(t *testing.T) {
+	var gotSecret string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			t.Fatalf("could not parse device authorization form: %v", err)
+		}
+		gotSecret = r.Form.Get("client_secret")
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(deviceAuthResponse{DeviceCode: "device-code", UserCode: "USER-CODE"})
+	}))
+	defer srv.Close()
+
+	cfg := &oauth2.Config{ClientID: "test-client", ClientSecret: "s3cr3t"}
+
+	if _, err := requestDeviceAuth(context.Background(), srv.URL, cfg); err != nil {
+		t.Fatalf("requestDeviceAuth() returned error: %v", err)
+	}
+
+	if gotSecret != "s3cr3t" {
+		t.Errorf("client_secret = %q, want s3cr3t", gotSecret)
+	}
+}
@@ -0,0 +1,77 @@
+package oauth2cli
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"golang.org/x/oauth2"
+)
+
+// OOBRedirectURI is the out-of-band redirect URI recognised by providers that display the
+// authorization code for the user to copy, rather than redirecting to a loopback server.
+const OOBRedirectURI = "urn:ietf:wg:oauth:2.0:oob"
+
+// TokenManual completes the OAuth2 flow without starting a local server, for headless
+// machines where a loopback redirect can't be received. cfg.RedirectURL is set to
+// OOBRedirectURI, the auth URL is printed to out, and the code pasted back by the user is
+// read from in before being exchanged for a token.
+//
+// out and in default to os.Stdout and os.Stdin if nil.
+func TokenManual(ctx context.Context, cfg *oauth2.Config, out io.Writer, in io.Reader, opts ...Option) (*oauth2.Token, error) {
+	o := applyOptions(opts)
+
+	if out == nil {
+		out = os.Stdout
+	}
+	if in == nil {
+		in = os.Stdin
+	}
+
+	cfg.RedirectURL = OOBRedirectURI
+
+	state, err := randomToken(16)
+	if err != nil {
+		return nil, err
+	}
+
+	verifier, authParams, err := pkceAuthParams(o)
+	if err != nil {
+		return nil, err
+	}
+
+	url := cfg.AuthCodeURL(state, authParams...)
+
+	fmt.Fprintf(out, "Go to the following URL in your browser, then paste the resulting code below:\n\n%s\n\nCode: ", url)
+
+	code, err := readLine(in)
+	if err != nil {
+		return nil, fmt.Errorf("could not read code: %w", err)
+	}
+
+	exchangeParams := pkceExchangeParams(o, verifier)
+
+	// Exchange the code for an OAuth2 token.
+	token, err := cfg.Exchange(ctx, code, exchangeParams...)
+	if err != nil {
+		return nil, fmt.Errorf("could not exchange for token: %w", err)
+	}
+
+	return token, nil
+}
+
+// readLine reads a single line from in, trimming surrounding whitespace.
+func readLine(in io.Reader) (string, error) {
+	scanner := bufio.NewScanner(in)
+	if !scanner.Scan() {
+		if err := scanner.Err(); err != nil {
+			return "", err
+		}
+		return "", io.ErrUnexpectedEOF
+	}
+
+	return strings.TrimSpace(scanner.Text()), nil
+}
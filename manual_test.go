@@ -0,0 +1,103 @@
+package oauth2cli
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"golang.org/x/oauth2"
+)
+
+// newManualTokenServer starts a token endpoint that records the form values it received
+// in got and always responds with a fixed access token.
+func newManualTokenServer(t *testing.T, got *url.Values) *httptest.Server {
+	t.Helper()
+
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			t.Fatalf("could not parse token request form: %v", err)
+		}
+		*got = r.Form
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"access_token": "access-token",
+			"token_type":   "Bearer",
+		})
+	}))
+}
+
+func TestTokenManualRoundTripsPastedCode(t *testing.T) {
+	var got url.Values
+	srv := newManualTokenServer(t, &got)
+	defer srv.Close()
+
+	cfg := &oauth2.Config{
+		ClientID: "test-client",
+		Endpoint: oauth2.Endpoint{AuthURL: "https://example.com/authorize", TokenURL: srv.URL},
+	}
+
+	var out bytes.Buffer
+	in := strings.NewReader("pasted-code\n")
+
+	tok, err := TokenManual(context.Background(), cfg, &out, in)
+	if err != nil {
+		t.Fatalf("TokenManual() returned error: %v", err)
+	}
+	if tok.AccessToken != "access-token" {
+		t.Errorf("AccessToken = %q, want access-token", tok.AccessToken)
+	}
+
+	if !strings.Contains(out.String(), "https://example.com/authorize") {
+		t.Errorf("printed output = %q, want it to contain the auth URL", out.String())
+	}
+
+	if got.Get("code") != "pasted-code" {
+		t.Errorf("token request code = %q, want pasted-code", got.Get("code"))
+	}
+}
+
+func TestTokenManualWithPKCEIncludesChallengeAndVerifier(t *testing.T) {
+	var got url.Values
+	srv := newManualTokenServer(t, &got)
+	defer srv.Close()
+
+	cfg := &oauth2.Config{
+		ClientID: "test-client",
+		Endpoint: oauth2.Endpoint{AuthURL: "https://example.com/authorize", TokenURL: srv.URL},
+	}
+
+	var out bytes.Buffer
+	in := strings.NewReader("pasted-code\n")
+
+	if _, err := TokenManual(context.Background(), cfg, &out, in, WithPKCE()); err != nil {
+		t.Fatalf("TokenManual() returned error: %v", err)
+	}
+
+	lines := strings.Split(out.String(), "\n")
+	if len(lines) < 3 {
+		t.Fatalf("unexpected output format: %q", out.String())
+	}
+	authURL, err := url.Parse(lines[2])
+	if err != nil {
+		t.Fatalf("could not parse printed auth URL: %v", err)
+	}
+
+	challenge := authURL.Query().Get("code_challenge")
+	if challenge == "" || authURL.Query().Get("code_challenge_method") != "S256" {
+		t.Fatalf("auth URL %q missing PKCE challenge params", lines[2])
+	}
+
+	verifier := got.Get("code_verifier")
+	if verifier == "" {
+		t.Fatal("token request did not include code_verifier")
+	}
+	if codeChallengeS256(verifier) != challenge {
+		t.Errorf("code_verifier %q does not match code_challenge %q", verifier, challenge)
+	}
+}
@@ -0,0 +1,184 @@
+package oauth2cli
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-jose/go-jose/v4"
+	"github.com/go-jose/go-jose/v4/jwt"
+	"golang.org/x/oauth2"
+)
+
+// newOIDCTestProvider starts an httptest.Server serving OIDC discovery, JWKS and token
+// endpoints. The token endpoint always returns a fixed access token plus an id_token signed
+// with the nonce received from nonceCh, letting the test control whether the issued token's
+// nonce matches the one TokenOIDC actually sent.
+func newOIDCTestProvider(t *testing.T, nonceCh <-chan string) *httptest.Server {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("could not generate signing key: %v", err)
+	}
+
+	mux := http.NewServeMux()
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+
+	jwk := jose.JSONWebKey{Key: &key.PublicKey, KeyID: "test-key", Algorithm: string(jose.RS256), Use: "sig"}
+
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"issuer":                                srv.URL,
+			"authorization_endpoint":                srv.URL + "/authorize",
+			"token_endpoint":                        srv.URL + "/token",
+			"jwks_uri":                              srv.URL + "/jwks",
+			"id_token_signing_alg_values_supported": []string{"RS256"},
+		})
+	})
+
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(jose.JSONWebKeySet{Keys: []jose.JSONWebKey{jwk}})
+	})
+
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			t.Fatalf("could not parse token request form: %v", err)
+		}
+
+		signer, err := jose.NewSigner(jose.SigningKey{Algorithm: jose.RS256, Key: key}, (&jose.SignerOptions{}).WithHeader("kid", "test-key"))
+		if err != nil {
+			t.Fatalf("could not build signer: %v", err)
+		}
+
+		clientID := r.Form.Get("client_id")
+		if clientID == "" {
+			clientID, _, _ = r.BasicAuth()
+		}
+
+		now := time.Now()
+		claims := map[string]any{
+			"iss":   srv.URL,
+			"sub":   "user-123",
+			"aud":   clientID,
+			"exp":   now.Add(time.Hour).Unix(),
+			"iat":   now.Unix(),
+			"nonce": <-nonceCh,
+		}
+		rawIDToken, err := jwt.Signed(signer).Claims(claims).Serialize()
+		if err != nil {
+			t.Fatalf("could not sign id_token: %v", err)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"access_token": "access-token",
+			"token_type":   "Bearer",
+			"id_token":     rawIDToken,
+		})
+	})
+
+	return srv
+}
+
+// runTokenOIDC starts TokenOIDC on a background goroutine, captures the auth URL via a
+// stubbed openBrowser, delivers a matching-state callback, and returns the generated auth
+// URL and its nonce param alongside a channel receiving TokenOIDC's error.
+func runTokenOIDC(t *testing.T, cfg *oauth2.Config, issuerURL string) (authURL, nonce string, errCh <-chan error) {
+	t.Helper()
+
+	urlCh := make(chan string, 1)
+	stubOpenBrowser(t, func(u string) error {
+		urlCh <- u
+		return nil
+	})
+
+	resultCh := make(chan error, 1)
+	go func() {
+		_, _, err := TokenOIDC(context.Background(), cfg, 0, "", issuerURL)
+		resultCh <- err
+	}()
+
+	authURL = <-urlCh
+	parsed, err := url.Parse(authURL)
+	if err != nil {
+		t.Fatalf("could not parse auth URL: %v", err)
+	}
+	nonce = parsed.Query().Get("nonce")
+	if nonce == "" {
+		t.Fatalf("auth URL %q missing a nonce param", authURL)
+	}
+
+	resp, err := http.Get(cfg.RedirectURL + "?state=" + parsed.Query().Get("state") + "&code=auth-code")
+	if err != nil {
+		t.Fatalf("callback request failed: %v", err)
+	}
+	resp.Body.Close()
+
+	return authURL, nonce, resultCh
+}
+
+func TestTokenOIDCAuthURLIncludesNonce(t *testing.T) {
+	nonceCh := make(chan string, 1)
+	issuerSrv := newOIDCTestProvider(t, nonceCh)
+
+	cfg := &oauth2.Config{
+		ClientID: "test-client",
+		Endpoint: oauth2.Endpoint{TokenURL: issuerSrv.URL + "/token"},
+	}
+
+	_, nonce, errCh := runTokenOIDC(t, cfg, issuerSrv.URL)
+	nonceCh <- nonce // echo the real nonce back so verification succeeds
+
+	if err := <-errCh; err != nil {
+		t.Fatalf("TokenOIDC() returned error: %v", err)
+	}
+}
+
+func TestTokenOIDCRejectsNonceMismatch(t *testing.T) {
+	nonceCh := make(chan string, 1)
+	issuerSrv := newOIDCTestProvider(t, nonceCh)
+
+	cfg := &oauth2.Config{
+		ClientID: "test-client",
+		Endpoint: oauth2.Endpoint{TokenURL: issuerSrv.URL + "/token"},
+	}
+
+	_, _, errCh := runTokenOIDC(t, cfg, issuerSrv.URL)
+	nonceCh <- "wrong-nonce"
+
+	err := <-errCh
+	if err == nil {
+		t.Fatal("TokenOIDC() expected an error for a mismatched nonce, got nil")
+	}
+}
+
+func TestTokenOIDCAuthURLUsesDiscoveredEndpoint(t *testing.T) {
+	nonceCh := make(chan string, 1)
+	issuerSrv := newOIDCTestProvider(t, nonceCh)
+
+	// Leave cfg.Endpoint unset entirely - it must be populated from the issuer's
+	// discovery document rather than producing a scheme-and-host-less auth URL.
+	cfg := &oauth2.Config{ClientID: "test-client"}
+
+	authURL, nonce, errCh := runTokenOIDC(t, cfg, issuerSrv.URL)
+	nonceCh <- nonce
+
+	if !strings.HasPrefix(authURL, issuerSrv.URL+"/authorize?") {
+		t.Errorf("auth URL = %q, want it to start with the discovered authorization_endpoint %q", authURL, issuerSrv.URL+"/authorize")
+	}
+
+	if err := <-errCh; err != nil {
+		t.Fatalf("TokenOIDC() returned error: %v", err)
+	}
+}
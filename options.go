@@ -0,0 +1,46 @@
+package oauth2cli
+
+import (
+	"html/template"
+	"io"
+)
+
+// Option configures optional behaviour of the flows provided by this package.
+type Option func(*options)
+
+// options holds the resolved configuration built up from a set of Option values.
+type options struct {
+	pkce bool
+
+	appName     string
+	successHTML string
+	successTmpl *template.Template
+
+	deviceOut io.Writer
+	qrCode    func(io.Writer, string)
+}
+
+// hasSuccessPage reports whether a success page has been configured via WithSuccessHTML
+// or WithSuccessTemplate.
+func (o *options) hasSuccessPage() bool {
+	return o.successHTML != "" || o.successTmpl != nil
+}
+
+// WithPKCE enables PKCE (RFC 7636) for the authorization code flow: a random
+// code_verifier is generated, its S256 code_challenge is sent with the auth
+// request, and the verifier is sent with the token exchange. Public clients
+// that cannot hold a client secret should always enable this.
+func WithPKCE() Option {
+	return func(o *options) {
+		o.pkce = true
+	}
+}
+
+// applyOptions builds an options value from the given Option list.
+func applyOptions(opts []Option) *options {
+	o := &options{}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
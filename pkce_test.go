@@ -0,0 +1,41 @@
+package oauth2cli
+
+import (
+	"regexp"
+	"testing"
+)
+
+var pkceUnreservedCharset = regexp.MustCompile(`^[A-Za-z0-9\-._~]+$`)
+
+func TestGenerateCodeVerifier(t *testing.T) {
+	verifier, err := generateCodeVerifier()
+	if err != nil {
+		t.Fatalf("generateCodeVerifier() returned error: %v", err)
+	}
+
+	if l := len(verifier); l < 43 || l > 128 {
+		t.Errorf("verifier length = %d, want between 43 and 128", l)
+	}
+
+	if !pkceUnreservedCharset.MatchString(verifier) {
+		t.Errorf("verifier %q contains characters outside the PKCE unreserved set", verifier)
+	}
+
+	other, err := generateCodeVerifier()
+	if err != nil {
+		t.Fatalf("generateCodeVerifier() returned error: %v", err)
+	}
+	if verifier == other {
+		t.Error("generateCodeVerifier() returned the same value on successive calls")
+	}
+}
+
+func TestCodeChallengeS256(t *testing.T) {
+	// Known-answer test vector from RFC 7636 appendix B.
+	const verifier = "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"
+	const want = "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM"
+
+	if got := codeChallengeS256(verifier); got != want {
+		t.Errorf("codeChallengeS256(%q) = %q, want %q", verifier, got, want)
+	}
+}
@@ -0,0 +1,19 @@
+package oauth2cli
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+)
+
+// randomToken returns a cryptographically random, base64url-encoded token generated from
+// n raw bytes of entropy. It backs the state, nonce and PKCE verifier values used across
+// the package's flows.
+func randomToken(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("could not generate random token: %w", err)
+	}
+
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
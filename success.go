@@ -0,0 +1,73 @@
+package oauth2cli
+
+import (
+	"bytes"
+	"html/template"
+	"net/http"
+)
+
+// SuccessPageData is made available to a SuccessHTML or SuccessTemplate, for rendering a
+// branded "you can close this tab" page once the callback has been handled.
+type SuccessPageData struct {
+	// AppName is the value passed to WithAppName, if any.
+	AppName string
+	// Error is set if the callback failed, e.g. due to a provider error or invalid state.
+	Error string
+}
+
+// WithAppName sets the AppName field made available to a SuccessHTML or SuccessTemplate.
+func WithAppName(name string) Option {
+	return func(o *options) {
+		o.appName = name
+	}
+}
+
+// WithSuccessHTML renders html as the callback page once the flow completes. html is
+// parsed as an html/template and executed with a SuccessPageData value, so it may
+// reference fields such as {{.AppName}} and {{.Error}}.
+func WithSuccessHTML(html string) Option {
+	return func(o *options) {
+		o.successHTML = html
+	}
+}
+
+// WithSuccessTemplate renders tmpl as the callback page once the flow completes, executed
+// with a SuccessPageData value. Use this instead of WithSuccessHTML if the template has
+// already been parsed, e.g. to reuse it across calls.
+func WithSuccessTemplate(tmpl *template.Template) Option {
+	return func(o *options) {
+		o.successTmpl = tmpl
+	}
+}
+
+// fallbackSuccessBody is served in place of a configured success page that fails to
+// parse or execute, so the user isn't left looking at a blank tab with no indication
+// anything went wrong.
+const fallbackSuccessBody = "Authentication complete. You can close this tab and return to the application."
+
+// renderSuccessPage writes the configured success page, if any, to w. The page is rendered
+// into a buffer first, so a template that fails to parse or execute partway through never
+// leaves w with a mix of partial output and fallbackSuccessBody.
+func renderSuccessPage(w http.ResponseWriter, o *options, data SuccessPageData) {
+	tmpl := o.successTmpl
+	if o.successHTML != "" {
+		t, err := template.New("success").Parse(o.successHTML)
+		if err != nil {
+			http.Error(w, fallbackSuccessBody, http.StatusOK)
+			return
+		}
+		tmpl = t
+	}
+
+	if tmpl == nil {
+		return
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		http.Error(w, fallbackSuccessBody, http.StatusOK)
+		return
+	}
+
+	_, _ = buf.WriteTo(w)
+}
@@ -0,0 +1,91 @@
+package oauth2cli
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+
+	"golang.org/x/oauth2"
+)
+
+// NotifyingTokenSource wraps cfg.TokenSource(ctx, tok), invoking onChange whenever a refresh
+// produces a token whose AccessToken, RefreshToken or Expiry differs from the last one
+// observed. If onChange returns an error, Token() returns that error instead of the
+// refreshed token, so a failure to persist a rotated refresh token isn't silently dropped.
+func NotifyingTokenSource(ctx context.Context, cfg *oauth2.Config, tok *oauth2.Token, onChange func(*oauth2.Token) error) oauth2.TokenSource {
+	return &notifyingTokenSource{
+		src:      cfg.TokenSource(ctx, tok),
+		last:     tok,
+		onChange: onChange,
+	}
+}
+
+type notifyingTokenSource struct {
+	mu       sync.Mutex
+	src      oauth2.TokenSource
+	last     *oauth2.Token
+	onChange func(*oauth2.Token) error
+}
+
+func (n *notifyingTokenSource) Token() (*oauth2.Token, error) {
+	tok, err := n.src.Token()
+	if err != nil {
+		return nil, err
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if tokenChanged(n.last, tok) {
+		if err := n.onChange(tok); err != nil {
+			return nil, fmt.Errorf("could not persist refreshed token: %w", err)
+		}
+		n.last = tok
+	}
+
+	return tok, nil
+}
+
+func tokenChanged(a, b *oauth2.Token) bool {
+	return a.AccessToken != b.AccessToken || a.RefreshToken != b.RefreshToken || !a.Expiry.Equal(b.Expiry)
+}
+
+// FileCache returns an onChange callback for NotifyingTokenSource that serialises the
+// token as JSON to path, with permissions restricted to the owner.
+func FileCache(path string) func(*oauth2.Token) error {
+	return func(tok *oauth2.Token) error {
+		b, err := json.Marshal(tok)
+		if err != nil {
+			return fmt.Errorf("could not marshal token: %w", err)
+		}
+
+		if err := os.WriteFile(path, b, 0o600); err != nil {
+			return fmt.Errorf("could not write token cache: %w", err)
+		}
+
+		// os.WriteFile only applies the mode when it creates the file.
+		if err := os.Chmod(path, 0o600); err != nil {
+			return fmt.Errorf("could not restrict token cache permissions: %w", err)
+		}
+
+		return nil
+	}
+}
+
+// LoadTokenFile reads back a token previously persisted via FileCache, for seeding
+// NotifyingTokenSource on a subsequent run without re-prompting the user.
+func LoadTokenFile(path string) (*oauth2.Token, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not read token cache: %w", err)
+	}
+
+	var tok oauth2.Token
+	if err := json.Unmarshal(b, &tok); err != nil {
+		return nil, fmt.Errorf("could not unmarshal token cache: %w", err)
+	}
+
+	return &tok, nil
+}
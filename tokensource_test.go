@@ -0,0 +1,146 @@
+package oauth2cli
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+func TestTokenChanged(t *testing.T) {
+	base := &oauth2.Token{
+		AccessToken:  "access",
+		RefreshToken: "refresh",
+		Expiry:       time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC),
+	}
+
+	tests := map[string]struct {
+		b    *oauth2.Token
+		want bool
+	}{
+		"identical": {
+			b:    &oauth2.Token{AccessToken: "access", RefreshToken: "refresh", Expiry: base.Expiry},
+			want: false,
+		},
+		"different access token": {
+			b:    &oauth2.Token{AccessToken: "new-access", RefreshToken: "refresh", Expiry: base.Expiry},
+			want: true,
+		},
+		"different refresh token": {
+			b:    &oauth2.Token{AccessToken: "access", RefreshToken: "new-refresh", Expiry: base.Expiry},
+			want: true,
+		},
+		"different expiry": {
+			b:    &oauth2.Token{AccessToken: "access", RefreshToken: "refresh", Expiry: base.Expiry.Add(time.Hour)},
+			want: true,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			if got := tokenChanged(base, tt.b); got != tt.want {
+				t.Errorf("tokenChanged() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// stubTokenSource returns each token in tokens in order, repeating the last one once
+// exhausted.
+type stubTokenSource struct {
+	tokens []*oauth2.Token
+	i      int
+}
+
+func (s *stubTokenSource) Token() (*oauth2.Token, error) {
+	tok := s.tokens[s.i]
+	if s.i < len(s.tokens)-1 {
+		s.i++
+	}
+	return tok, nil
+}
+
+func TestNotifyingTokenSourceNotifiesOnlyOnChange(t *testing.T) {
+	first := &oauth2.Token{AccessToken: "a"}
+	second := &oauth2.Token{AccessToken: "b"}
+
+	var notified []*oauth2.Token
+	src := &notifyingTokenSource{
+		src:  &stubTokenSource{tokens: []*oauth2.Token{first, second}},
+		last: first,
+		onChange: func(tok *oauth2.Token) error {
+			notified = append(notified, tok)
+			return nil
+		},
+	}
+
+	if _, err := src.Token(); err != nil {
+		t.Fatalf("Token() #1 returned error: %v", err)
+	}
+	if len(notified) != 0 {
+		t.Fatalf("onChange called for an unchanged token")
+	}
+
+	if _, err := src.Token(); err != nil {
+		t.Fatalf("Token() #2 returned error: %v", err)
+	}
+	if len(notified) != 1 || notified[0] != second {
+		t.Fatalf("onChange not called with the refreshed token: got %v", notified)
+	}
+}
+
+func TestNotifyingTokenSourcePropagatesOnChangeError(t *testing.T) {
+	first := &oauth2.Token{AccessToken: "a"}
+	second := &oauth2.Token{AccessToken: "b"}
+	wantErr := errors.New("could not persist token")
+
+	src := &notifyingTokenSource{
+		src:  &stubTokenSource{tokens: []*oauth2.Token{first, second}},
+		last: first,
+		onChange: func(tok *oauth2.Token) error {
+			return wantErr
+		},
+	}
+
+	if _, err := src.Token(); err != nil {
+		t.Fatalf("Token() #1 returned error: %v", err)
+	}
+
+	if _, err := src.Token(); err == nil || !errors.Is(err, wantErr) {
+		t.Fatalf("Token() #2 error = %v, want wrapped %v", err, wantErr)
+	}
+}
+
+func TestFileCacheRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "token.json")
+
+	tok := &oauth2.Token{
+		AccessToken:  "access",
+		RefreshToken: "refresh",
+		Expiry:       time.Now().Round(time.Second),
+	}
+
+	if err := FileCache(path)(tok); err != nil {
+		t.Fatalf("FileCache save returned error: %v", err)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("could not stat cache file: %v", err)
+	}
+	if perm := info.Mode().Perm(); perm != 0o600 {
+		t.Errorf("cache file permissions = %v, want 0600", perm)
+	}
+
+	got, err := LoadTokenFile(path)
+	if err != nil {
+		t.Fatalf("LoadTokenFile() returned error: %v", err)
+	}
+
+	if got.AccessToken != tok.AccessToken || got.RefreshToken != tok.RefreshToken || !got.Expiry.Equal(tok.Expiry) {
+		t.Errorf("LoadTokenFile() = %+v, want %+v", got, tok)
+	}
+}